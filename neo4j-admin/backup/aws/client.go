@@ -0,0 +1,18 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// awsClient talks to Amazon S3 using the AWS SDK. It implements
+// storage.Provider.
+type awsClient struct {
+	cfg *aws.Config
+}
+
+// NewClient builds an awsClient around an already-resolved AWS config (e.g.
+// loaded via config.LoadDefaultConfig, picking up the pod's IRSA/role
+// credentials or explicit AWS_* env vars).
+func NewClient(cfg *aws.Config) *awsClient {
+	return &awsClient{cfg: cfg}
+}
@@ -0,0 +1,27 @@
+package aws
+
+import "testing"
+
+func TestDedupEnabledDefaultsToTrue(t *testing.T) {
+	t.Setenv("DEDUP_ENABLED", "")
+	if !dedupEnabled() {
+		t.Errorf("expected dedup to default to enabled when DEDUP_ENABLED is unset")
+	}
+}
+
+// TestDedupEnabledFalseFallsBackToWholeFileUpload covers the `--no-dedup`
+// escape hatch: UploadFile's dedup branch must be skippable so buckets where
+// the chunk index overhead isn't worth it keep today's whole-file behaviour.
+func TestDedupEnabledFalseFallsBackToWholeFileUpload(t *testing.T) {
+	t.Setenv("DEDUP_ENABLED", "false")
+	if dedupEnabled() {
+		t.Errorf("expected DEDUP_ENABLED=false to disable dedup and fall back to whole-file upload")
+	}
+}
+
+func TestDedupEnabledIgnoresUnparseableValue(t *testing.T) {
+	t.Setenv("DEDUP_ENABLED", "not-a-bool")
+	if !dedupEnabled() {
+		t.Errorf("expected an unparseable DEDUP_ENABLED to fall back to the safe default (enabled)")
+	}
+}
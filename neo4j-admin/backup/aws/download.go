@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/cas"
+)
+
+// DownloadFile fetches fileName from bucketName down to location on disk,
+// ready for `neo4j-admin load`. When the object was written under SSE-C, the
+// same customer key must be resupplied on the GET or S3 rejects the
+// request, so this reads ENCRYPTION_MODE/SSE_C_KEY_FILE exactly like the
+// upload path does.
+//
+// A backup taken with dedup enabled never wrote a whole-object key - it
+// wrote content-addressed chunks plus a "<file>.snapshot.json" manifest
+// instead - so this first checks for that manifest and, if present,
+// restores through cas.Download rather than a plain GetObject that would
+// otherwise 404.
+func (a *awsClient) DownloadFile(fileName string, location string, bucketName string) error {
+	parentBucketName := bucketName
+	if strings.Contains(bucketName, "/") {
+		parentBucketName = bucketName[:strings.Index(bucketName, "/")]
+	}
+
+	encryption, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+
+	s3Client := a.getS3Client()
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
+
+	hasSnapshot, err := cas.HasSnapshot(context.TODO(), s3Client, parentBucketName, bucketName, fileName, encryption)
+	if err != nil {
+		return err
+	}
+	if hasSnapshot {
+		store := cas.NewStore(s3Client, parentBucketName, "", encryption)
+		if err := cas.Download(context.TODO(), s3Client, store, parentBucketName, bucketName, fileName, filePath, encryption); err != nil {
+			return err
+		}
+		log.Printf("File %s restored from deduplicated chunks in s3 bucket %s !!", fileName, bucketName)
+		return nil
+	}
+
+	getInput := &s3.GetObjectInput{
+		Bucket: aws.String(parentBucketName),
+		Key:    aws.String(generateKeyName(bucketName, fileName)),
+	}
+	encryption.ApplyToGet(getInput)
+
+	output, err := s3Client.GetObject(context.TODO(), getInput)
+	if err != nil {
+		return fmt.Errorf("Couldn't download file %v from bucket %v. Here's why: %v\n", fileName, bucketName, err)
+	}
+	defer output.Body.Close()
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("Couldn't create file %v to download into. Here's why: %v\n", filePath, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, output.Body); err != nil {
+		return fmt.Errorf("Couldn't write downloaded file %v. Here's why: %v\n", filePath, err)
+	}
+
+	log.Printf("File %s downloaded from s3 bucket %s !!", fileName, bucketName)
+	return nil
+}
@@ -0,0 +1,87 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// backupManifest is persisted as a sibling "<backup>.manifest.json" object
+// after every successful upload, so a later restore job can re-verify a
+// downloaded file's integrity before handing it to `neo4j-admin load`,
+// instead of only discovering a truncated/corrupted upload at restore time.
+type backupManifest struct {
+	FileName string `json:"fileName"`
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+}
+
+func manifestKey(bucketName string, fileName string) string {
+	return fmt.Sprintf("%s.manifest.json", generateKeyName(bucketName, fileName))
+}
+
+func writeManifest(ctx context.Context, s3Client *s3.Client, parentBucketName string, bucketName string, manifest backupManifest, encryption encryptionConfig) error {
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	putInput := &s3.PutObjectInput{
+		Bucket: aws.String(parentBucketName),
+		Key:    aws.String(manifestKey(bucketName, manifest.FileName)),
+		Body:   bytes.NewReader(body),
+	}
+	encryption.ApplyToPut(putInput)
+	_, err = s3Client.PutObject(ctx, putInput)
+	if err != nil {
+		return fmt.Errorf("Couldn't write manifest for %v. Here's why: %v\n", manifest.FileName, err)
+	}
+	return nil
+}
+
+// checksumHashingReader tees every byte read through it into a sha256
+// digest, so the upload's own read of the file produces the verification
+// checksum - there is no separate pass over the file after the upload
+// completes.
+type checksumHashingReader struct {
+	io.Reader
+	hash hash.Hash
+}
+
+func newChecksumHashingReader(r io.Reader) *checksumHashingReader {
+	h := sha256.New()
+	return &checksumHashingReader{Reader: io.TeeReader(r, h), hash: h}
+}
+
+func (c *checksumHashingReader) base64Sum() string {
+	return base64.StdEncoding.EncodeToString(c.hash.Sum(nil))
+}
+
+func (c *checksumHashingReader) hexSum() string {
+	return hex.EncodeToString(c.hash.Sum(nil))
+}
+
+// verifyChecksum compares the digest this process computed while streaming
+// the upload against the checksum S3 stored for the object (returned as
+// x-amz-checksum-sha256, base64-encoded). A nil remoteChecksum means S3
+// didn't echo one back (e.g. checksums weren't requested) and is treated as
+// "nothing to verify" rather than a failure.
+func verifyChecksum(fileName string, localChecksum string, remoteChecksum *string) error {
+	if remoteChecksum == nil {
+		log.Printf("No checksum returned by the object store for %s; skipping integrity verification", fileName)
+		return nil
+	}
+	if localChecksum != *remoteChecksum {
+		return fmt.Errorf("checksum mismatch for %s: uploaded object's sha256 (%s) does not match the sha256 computed while streaming (%s) - the upload may be truncated or corrupted", fileName, *remoteChecksum, localChecksum)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/cas"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/retention"
+)
+
+// EnforceRetention sweeps bucketName (optionally "bucket/prefix") for
+// backups that fall outside the configured daily/weekly/monthly generations
+// and deletes them. It is separate from the upload itself: neither
+// UploadFile nor UploadLargeObject calls it.
+//
+// When dedup is enabled, expired backups only ever point at content-
+// addressed chunks shared bucket-wide - deleting the tagged snapshot/
+// whole-object key alone never reclaims the chunks themselves, so this also
+// runs cas.GC to delete chunks no surviving snapshot anywhere in the bucket
+// still references.
+func (a *awsClient) EnforceRetention(bucketName string) error {
+	parentBucketName := bucketName
+	prefix := ""
+	if strings.Contains(bucketName, "/") {
+		index := strings.Index(bucketName, "/")
+		parentBucketName = bucketName[:index]
+		prefix = bucketName[index+1:]
+	}
+
+	s3Client := a.getS3Client()
+	now := time.Now()
+	if err := retention.Sweep(context.TODO(), s3Client, parentBucketName, prefix, retention.LoadPolicy(), now); err != nil {
+		return err
+	}
+
+	if !dedupEnabled() {
+		return nil
+	}
+	encryption, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+	_, err = cas.GC(context.TODO(), s3Client, parentBucketName, encryption, now)
+	return err
+}
+
+// applyLifecycle tags a just-uploaded backup object with its type/db/
+// timestamp (for the retention sweep to classify it later) and, when
+// backup.objectLock.enabled=true, locks it against early deletion for the
+// configured minimum retention window.
+func applyLifecycle(ctx context.Context, s3Client *s3.Client, bucketName string, key string, uploadedAt time.Time) error {
+	backupType := retention.BackupType(strings.TrimSpace(os.Getenv("BACKUP_TYPE")))
+	if backupType == "" {
+		backupType = retention.Full
+	}
+	db := strings.TrimSpace(os.Getenv("DB_NAME"))
+
+	if err := retention.TagObject(ctx, s3Client, bucketName, key, backupType, db, uploadedAt); err != nil {
+		return err
+	}
+
+	if !retention.ObjectLockEnabled() {
+		return nil
+	}
+
+	mode := types.ObjectLockRetentionMode(strings.ToUpper(strings.TrimSpace(os.Getenv("OBJECT_LOCK_MODE"))))
+	if mode == "" {
+		mode = types.ObjectLockRetentionModeGovernance
+	}
+	minRetention := time.Duration(retention.LoadPolicy().MinRetentionHours) * time.Hour
+	return retention.LockObject(ctx, s3Client, bucketName, key, mode, minRetention, uploadedAt)
+}
@@ -0,0 +1,141 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+)
+
+// minioClient talks to a MinIO (or any S3-compatible) endpoint using the
+// native minio-go SDK. It exists so that MinIO installs no longer have to be
+// shoe-horned into the AWS SDK via a custom EndpointResolverV2 - that path
+// could not express virtual-host addressing and always forced path-style.
+//
+// It is selected whenever ENDPOINT is set and backup.storage.provider=minio;
+// the AWS SDK path (awsClient) remains the default for real S3.
+type minioClient struct {
+	client    *minio.Client
+	pathStyle bool
+	region    string
+	endpoint  string
+	useTLS    bool
+}
+
+// NewMinioClient builds a minioClient from the ENDPOINT env var plus the
+// region/path-style toggles the chart exposes under backup.storage.minio.*.
+func NewMinioClient(region string, pathStyle bool) (*minioClient, error) {
+	endpoint := strings.TrimSpace(os.Getenv("ENDPOINT"))
+	if endpoint == "" {
+		return nil, fmt.Errorf("ENDPOINT env var must be set to use the minio storage provider")
+	}
+	useTLS := true
+	if value := strings.TrimSpace(os.Getenv("ENDPOINT_USE_SSL")); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENDPOINT_USE_SSL value %q: %v", value, err)
+		}
+		useTLS = parsed
+	}
+
+	host := strings.TrimPrefix(strings.TrimPrefix(endpoint, "https://"), "http://")
+	client, err := minio.New(host, &minio.Options{
+		Creds:        credentials.NewEnvAWS(),
+		Secure:       useTLS,
+		Region:       region,
+		BucketLookup: bucketLookupType(pathStyle),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't create minio client for endpoint %v. Here's why: %v\n", endpoint, err)
+	}
+
+	return &minioClient{client: client, pathStyle: pathStyle, region: region, endpoint: endpoint, useTLS: useTLS}, nil
+}
+
+func bucketLookupType(pathStyle bool) minio.BucketLookupType {
+	if pathStyle {
+		return minio.BucketLookupPath
+	}
+	return minio.BucketLookupDNS
+}
+
+// CheckBucketAccess checks if the given bucket name is accessible or not
+func (m *minioClient) CheckBucketAccess(bucketName string) error {
+	name, _ := splitBucketAndPrefix(bucketName)
+	exists, err := m.client.BucketExists(context.TODO(), name)
+	if err != nil {
+		return fmt.Errorf("Unable to connect to minio bucket %s \n Here's why: %v\n", bucketName, err)
+	}
+	if !exists {
+		return fmt.Errorf("minio bucket %s does not exist", bucketName)
+	}
+	log.Printf("Connectivity with minio bucket '%s' established", bucketName)
+	return nil
+}
+
+// UploadFile uploads the file present at the provided location to the minio bucket
+func (m *minioClient) UploadFile(fileNames []string, bucketName string) error {
+	parentBucketName, _ := splitBucketAndPrefix(bucketName)
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+		filePath := fmt.Sprintf("%s/%s", location, fileName)
+		yes, err := common.IsFileBigger(filePath)
+		if err != nil {
+			return err
+		}
+		if yes {
+			if err := m.UploadLargeObject(fileName, location, bucketName, parentBucketName); err != nil {
+				return err
+			}
+			continue
+		}
+
+		log.Printf("Starting upload of file %s", filePath)
+		log.Printf("KeyName := %s", generateKeyName(bucketName, fileName))
+		_, err = m.client.FPutObject(context.TODO(), parentBucketName, generateKeyName(bucketName, fileName), filePath, minio.PutObjectOptions{})
+		if err != nil {
+			return fmt.Errorf("Couldn't upload file %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, err)
+		}
+		log.Printf("File %s uploaded to minio bucket %s !!", fileName, bucketName)
+	}
+	return nil
+}
+
+// UploadLargeObject uploads a single large file to minio. minio-go's
+// FPutObject already handles multi-part uploads internally once the file
+// exceeds its part-size threshold, so there is no separate manager to
+// configure here the way the AWS SDK needs one.
+func (m *minioClient) UploadLargeObject(fileName string, location string, bucketName string, parentBucketName string) error {
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
+
+	log.Printf("Starting upload of file %s", filePath)
+	log.Printf("KeyName := %s", generateKeyName(bucketName, fileName))
+	_, err := m.client.FPutObject(context.TODO(), parentBucketName, generateKeyName(bucketName, fileName), filePath, minio.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("Couldn't upload large file %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, err)
+	}
+	log.Printf("File (Large) %s uploaded to minio bucket %s !!", fileName, bucketName)
+	return nil
+}
+
+// GenerateEnvVariablesFromCredentials is a no-op for minio: the env vars it
+// would export (AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY) are already what the
+// credentials.NewEnvAWS() provider above reads, so they must already be set
+// in the pod environment.
+func (m *minioClient) GenerateEnvVariablesFromCredentials() error {
+	return nil
+}
+
+func splitBucketAndPrefix(bucketName string) (name string, prefix string) {
+	if !strings.Contains(bucketName, "/") {
+		return bucketName, ""
+	}
+	firstIndex := strings.Index(bucketName, "/")
+	return bucketName[:firstIndex], bucketName[firstIndex+1:]
+}
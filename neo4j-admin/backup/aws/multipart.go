@@ -0,0 +1,292 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const (
+	// s3MaxParts is S3's hard limit on the number of parts in a multipart
+	// upload.
+	s3MaxParts = 10_000
+	// s3MinPartSize is the smallest part size S3 accepts for any part other
+	// than the last.
+	s3MinPartSize = 5 * 1024 * 1024
+
+	defaultPartSize          = 1024 * 1024 * 1024 // 1 GiB, same default as before
+	defaultUploadConcurrency = 5
+)
+
+// uploadTuning holds the knobs `backup.uploader.*` exposes: how big parts
+// are, how many upload concurrently, and how fast the upload is allowed to
+// go.
+type uploadTuning struct {
+	partSize          int64
+	concurrency       int
+	maxBytesPerSecond int64
+}
+
+func loadUploadTuning() uploadTuning {
+	tuning := uploadTuning{partSize: defaultPartSize, concurrency: defaultUploadConcurrency}
+
+	if value := strings.TrimSpace(os.Getenv("UPLOAD_PART_SIZE_BYTES")); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			tuning.partSize = parsed
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv("UPLOAD_CONCURRENCY")); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			tuning.concurrency = parsed
+		}
+	}
+	if value := strings.TrimSpace(os.Getenv("UPLOAD_MAX_BYTES_PER_SECOND")); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			tuning.maxBytesPerSecond = parsed
+		}
+	}
+
+	return tuning
+}
+
+// adaptivePartSize grows requestedPartSize, if necessary, so that fileSize /
+// partSize never exceeds S3's 10,000-part ceiling - this is what lets a
+// multi-TB backup keep using a small, network-friendly part size without the
+// upload failing part-way through on "TooManyParts".
+func adaptivePartSize(fileSize int64, requestedPartSize int64) int64 {
+	if requestedPartSize < s3MinPartSize {
+		requestedPartSize = s3MinPartSize
+	}
+	minRequired := (fileSize + s3MaxParts - 1) / s3MaxParts
+	if minRequired > requestedPartSize {
+		return minRequired
+	}
+	return requestedPartSize
+}
+
+type partResult struct {
+	part types.CompletedPart
+	size int64
+}
+
+// hashSequencer feeds each part's bytes into a single whole-file sha256 as
+// soon as they arrive, but only in part order, so a multipart upload whose
+// parts finish out of order still produces the same digest as a sequential
+// read of the file - without ever reading the file a second time. A part
+// whose upload attempt fails contributes nothing; submit is only called
+// once an attempt has actually succeeded, so a retried part's bytes are
+// hashed exactly once.
+type hashSequencer struct {
+	mu      sync.Mutex
+	hasher  hash.Hash
+	next    int
+	pending map[int][]byte
+}
+
+func newHashSequencer() *hashSequencer {
+	return &hashSequencer{hasher: sha256.New(), pending: make(map[int][]byte)}
+}
+
+func (h *hashSequencer) submit(partNumber int, data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pending[partNumber] = data
+	for {
+		data, ok := h.pending[h.next]
+		if !ok {
+			return
+		}
+		h.hasher.Write(data)
+		delete(h.pending, h.next)
+		h.next++
+	}
+}
+
+func (h *hashSequencer) base64Sum() string {
+	return base64.StdEncoding.EncodeToString(h.hasher.Sum(nil))
+}
+
+func (h *hashSequencer) hexSum() string {
+	return hex.EncodeToString(h.hasher.Sum(nil))
+}
+
+// UploadLargeObject uploads fileName as a multipart upload, with part size
+// adapted to the file size, up to tuning.concurrency parts in flight at
+// once, all of them together capped by tuning.maxBytesPerSecond, and each
+// retried with backoff on transient failures.
+func (a *awsClient) UploadLargeObject(fileName string, location string, bucketName string, parentBucketName string) error {
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
+	key := generateKeyName(bucketName, fileName)
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("Couldn't open large file %v to upload. Here's why: %v\n", filePath, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	tuning := loadUploadTuning()
+	partSize := adaptivePartSize(info.Size(), tuning.partSize)
+
+	encryption, err := loadEncryptionConfig()
+	if err != nil {
+		return err
+	}
+
+	s3Client := a.getS3Client()
+	ctx := context.TODO()
+
+	log.Printf("Starting upload of file %s", filePath)
+	log.Printf("KeyName := %s", key)
+
+	createInput := &s3.CreateMultipartUploadInput{
+		Bucket:            aws.String(parentBucketName),
+		Key:               aws.String(key),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+		ChecksumType:      types.ChecksumTypeFullObject,
+	}
+	encryption.ApplyToCreateMultipartUpload(createInput)
+	created, err := s3Client.CreateMultipartUpload(ctx, createInput)
+	if err != nil {
+		return fmt.Errorf("Couldn't start multipart upload of %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, err)
+	}
+	uploadID := created.UploadId
+
+	numParts := int((info.Size() + partSize - 1) / partSize)
+	if numParts == 0 {
+		numParts = 1
+	}
+
+	bucket := newTokenBucket(tuning.maxBytesPerSecond)
+	results := make([]partResult, numParts)
+	errs := make([]error, numParts)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	// The whole-file sha256 is assembled from the exact bytes each part
+	// already tees on its way to S3, fed into hashSequencer in part order -
+	// no second read of the file.
+	hashSeq := newHashSequencer()
+
+	worker := func() {
+		defer wg.Done()
+		for partNumber := range jobs {
+			offset := int64(partNumber) * partSize
+			size := partSize
+			if remaining := info.Size() - offset; remaining < size {
+				size = remaining
+			}
+
+			err := withRetry(defaultMaxAttempts, func() error {
+				section := io.NewSectionReader(file, offset, size)
+				reader := newThrottledReader(section, bucket)
+				var tee bytes.Buffer
+				uploadPartInput := &s3.UploadPartInput{
+					Bucket:     aws.String(parentBucketName),
+					Key:        aws.String(key),
+					UploadId:   uploadID,
+					PartNumber: aws.Int32(int32(partNumber) + 1),
+					Body:       io.TeeReader(reader, &tee),
+				}
+				encryption.ApplyToUploadPart(uploadPartInput)
+				output, err := s3Client.UploadPart(ctx, uploadPartInput)
+				if err != nil {
+					return err
+				}
+				results[partNumber] = partResult{
+					part: types.CompletedPart{ETag: output.ETag, PartNumber: aws.Int32(int32(partNumber) + 1)},
+					size: size,
+				}
+				hashSeq.submit(partNumber, tee.Bytes())
+				return nil
+			})
+			if err != nil {
+				errs[partNumber] = err
+				continue
+			}
+			log.Printf("Uploaded part %d/%d of %s (%d bytes)", partNumber+1, numParts, fileName, size)
+		}
+	}
+
+	for i := 0; i < tuning.concurrency; i++ {
+		wg.Add(1)
+		go worker()
+	}
+	for partNumber := 0; partNumber < numParts; partNumber++ {
+		jobs <- partNumber
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, partErr := range errs {
+		if partErr != nil {
+			_, _ = s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(parentBucketName),
+				Key:      aws.String(key),
+				UploadId: uploadID,
+			})
+			return fmt.Errorf("Couldn't upload large file %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, partErr)
+		}
+	}
+
+	completedParts := make([]types.CompletedPart, numParts)
+	var totalBytes int64
+	for i, result := range results {
+		completedParts[i] = result.part
+		totalBytes += result.size
+	}
+	sort.Slice(completedParts, func(i, j int) bool {
+		return *completedParts[i].PartNumber < *completedParts[j].PartNumber
+	})
+
+	completeOutput, err := s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(parentBucketName),
+		Key:             aws.String(key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completedParts},
+	})
+	if err != nil {
+		return fmt.Errorf("Couldn't complete multipart upload of %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, err)
+	}
+
+	if err := verifyChecksum(fileName, hashSeq.base64Sum(), completeOutput.ChecksumSHA256); err != nil {
+		return err
+	}
+	if err := writeManifest(ctx, s3Client, parentBucketName, bucketName, backupManifest{
+		FileName: fileName,
+		SHA256:   hashSeq.hexSum(),
+		Size:     info.Size(),
+	}, encryption); err != nil {
+		return err
+	}
+	if err := applyLifecycle(ctx, s3Client, parentBucketName, key, time.Now()); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	throughputMBps := float64(totalBytes) / elapsed.Seconds() / (1024 * 1024)
+	log.Printf("File (Large) %s uploaded to s3 bucket %s in %s (%d parts, %.2f MiB/s) !!", fileName, bucketName, elapsed.Round(time.Millisecond), numParts, throughputMBps)
+	return nil
+}
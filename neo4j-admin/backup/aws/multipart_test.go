@@ -0,0 +1,30 @@
+package aws
+
+import "testing"
+
+func TestAdaptivePartSizeStaysUnderPartLimit(t *testing.T) {
+	const tenTB = 10 * 1024 * 1024 * 1024 * 1024
+
+	partSize := adaptivePartSize(tenTB, defaultPartSize)
+	numParts := (tenTB + partSize - 1) / partSize
+	if numParts > s3MaxParts {
+		t.Errorf("a 10TiB upload needs %d parts at size %d, exceeding the %d part limit", numParts, partSize, s3MaxParts)
+	}
+}
+
+func TestAdaptivePartSizeKeepsRequestedSizeWhenSufficient(t *testing.T) {
+	const oneGB = 1024 * 1024 * 1024
+	requested := int64(64 * 1024 * 1024)
+
+	partSize := adaptivePartSize(oneGB, requested)
+	if partSize != requested {
+		t.Errorf("expected the requested part size %d to be kept for a 1GiB file, got %d", requested, partSize)
+	}
+}
+
+func TestAdaptivePartSizeNeverGoesBelowS3Minimum(t *testing.T) {
+	partSize := adaptivePartSize(1024, 1024)
+	if partSize < s3MinPartSize {
+		t.Errorf("part size %d is below S3's minimum %d", partSize, s3MinPartSize)
+	}
+}
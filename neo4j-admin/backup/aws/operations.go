@@ -4,20 +4,34 @@ import (
 	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	smithyendpoints "github.com/aws/smithy-go/endpoints"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/cas"
 	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/retention"
+	"io"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
-type resolverV2 struct{}
-
-func (*resolverV2) ResolveEndpoint(ctx context.Context, params s3.EndpointParameters) (smithyendpoints.Endpoint, error) {
-	// fallback to default
-	return s3.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, params)
+// dedupEnabled reports whether content-addressed chunk dedup should be used
+// for this backup. It defaults to on; set DEDUP_ENABLED=false (wired from
+// Helm's `backup.dedup.enabled`, the chart's `--no-dedup` switch) to fall
+// back to today's whole-file upload behaviour, e.g. for buckets where the
+// chunk index overhead isn't worth it.
+func dedupEnabled() bool {
+	value := strings.TrimSpace(os.Getenv("DEDUP_ENABLED"))
+	if value == "" {
+		return true
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return true
+	}
+	return enabled
 }
 
 // CheckBucketAccess checks if the given bucket name is accessible or not
@@ -51,6 +65,12 @@ func (a *awsClient) CheckBucketAccess(bucketName string) error {
 	}
 	log.Printf("Connectivity with S3 Bucket '%s' established", bucketName)
 
+	parentBucketName := bucketName
+	if strings.Contains(bucketName, "/") {
+		parentBucketName = bucketName[:strings.Index(bucketName, "/")]
+	}
+	retention.CheckObjectLockPreflight(context.TODO(), client, parentBucketName)
+
 	return nil
 }
 
@@ -74,6 +94,30 @@ func (a *awsClient) UploadFile(fileNames []string, bucketName string) error {
 		if err != nil {
 			return err
 		}
+		if dedupEnabled() {
+			encryption, err := loadEncryptionConfig()
+			if err != nil {
+				return err
+			}
+			store := cas.NewStore(s3Client, parentBucketName, "", encryption)
+			snapshot, err := cas.UploadFile(context.TODO(), s3Client, store, bucketName, parentBucketName, fileName, filePath, encryption)
+			if err != nil {
+				return err
+			}
+
+			if err := writeManifest(context.TODO(), s3Client, parentBucketName, bucketName, backupManifest{
+				FileName: fileName,
+				SHA256:   snapshot.SHA256,
+				Size:     snapshot.Size,
+			}, encryption); err != nil {
+				return err
+			}
+			if err := applyLifecycle(context.TODO(), s3Client, parentBucketName, cas.SnapshotKey(bucketName, fileName), time.Now()); err != nil {
+				return err
+			}
+			continue
+		}
+
 		//use UploadLargeObject if file size is more than 1GB
 		if yes {
 			err = a.UploadLargeObject(fileName, location, bucketName, parentBucketName)
@@ -88,52 +132,61 @@ func (a *awsClient) UploadFile(fileNames []string, bucketName string) error {
 			return fmt.Errorf("Couldn't open file %v to upload. Here's why: %v\n", filePath, err)
 		}
 
+		encryption, err := loadEncryptionConfig()
+		if err != nil {
+			return err
+		}
+
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+
 		log.Printf("Starting upload of file %s", filePath)
 		log.Printf("KeyName := %s", generateKeyName(bucketName, fileName))
-		_, err = s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
-			Bucket: aws.String(parentBucketName),
-			Key:    aws.String(generateKeyName(bucketName, fileName)),
-			Body:   file,
+		var hashingBody *checksumHashingReader
+		err = withRetry(defaultMaxAttempts, func() error {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			hashingBody = newChecksumHashingReader(file)
+			putInput := &s3.PutObjectInput{
+				Bucket:            aws.String(parentBucketName),
+				Key:               aws.String(generateKeyName(bucketName, fileName)),
+				Body:              hashingBody,
+				ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+			}
+			encryption.ApplyToPut(putInput)
+			output, err := s3Client.PutObject(context.TODO(), putInput)
+			if err != nil {
+				return err
+			}
+			return verifyChecksum(fileName, hashingBody.base64Sum(), output.ChecksumSHA256)
 		})
 		if err != nil {
 			return fmt.Errorf("Couldn't upload file %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, err)
 		}
 		file.Close()
 		log.Printf("File %s uploaded to s3 bucket %s !!", fileName, bucketName)
+
+		uploadedAt := time.Now()
+		if err := writeManifest(context.TODO(), s3Client, parentBucketName, bucketName, backupManifest{
+			FileName: fileName,
+			SHA256:   hashingBody.hexSum(),
+			Size:     info.Size(),
+		}, encryption); err != nil {
+			return err
+		}
+		if err := applyLifecycle(context.TODO(), s3Client, parentBucketName, generateKeyName(bucketName, fileName), uploadedAt); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (a *awsClient) UploadLargeObject(fileName string, location string, bucketName string, parentBucketName string) error {
-	filePath := fmt.Sprintf("%s/%s", location, fileName)
-
-	//divide the file into 1GB parts
-	var partGiBs int64 = 1
-	s3Client := a.getS3Client()
-	uploader := manager.NewUploader(s3Client, func(u *manager.Uploader) {
-		u.PartSize = partGiBs * 1024 * 1024 * 1024
-	})
-
-	file, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("Couldn't open large file %v to upload. Here's why: %v\n", filePath, err)
-	}
-
-	defer file.Close()
-
-	log.Printf("Starting upload of file %s", filePath)
-	log.Printf("KeyName := %s", generateKeyName(bucketName, fileName))
-	_, err = uploader.Upload(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(parentBucketName),
-		Key:    aws.String(generateKeyName(bucketName, fileName)),
-		Body:   file,
-	})
-	if err != nil {
-		return fmt.Errorf("Couldn't upload large file %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, err)
-	}
-	log.Printf("File (Large) %s uploaded to s3 bucket %s !!", fileName, bucketName)
-	return err
-}
+// UploadLargeObject lives in multipart.go: it replaced the old fixed-size
+// manager.Uploader with a configurable, concurrent, rate-limited multipart
+// uploader.
 
 // GenerateEnvVariablesFromCredentials sets AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
 // This is required in the case when aggregate backup is to be performed but service account (role based creds) is not used
@@ -168,15 +221,18 @@ func generateKeyName(bucketName string, fileName string) string {
 	return keyName
 }
 
+// getS3Client builds the S3 client awsClient's methods use. ENDPOINT
+// (backup.storage.endpoint) overrides the default AWS endpoint resolution
+// with a fixed, path-style base endpoint - this is what lets the s3
+// provider, and the SSE-C integration test in encryption_test.go, be pointed
+// at a MinIO instance instead of real S3 without changing any other code
+// path.
 func (a *awsClient) getS3Client() *s3.Client {
-	client := s3.NewFromConfig(*a.cfg)
-	// if minio endpoint is provided add the endpoint resolver
-	if value := os.Getenv("ENDPOINT"); strings.TrimSpace(value) != "" {
-		client = s3.NewFromConfig(*a.cfg, func(options *s3.Options) {
+	if value := strings.TrimSpace(os.Getenv("ENDPOINT")); value != "" {
+		return s3.NewFromConfig(*a.cfg, func(options *s3.Options) {
 			options.BaseEndpoint = aws.String(value)
-			options.EndpointResolverV2 = &resolverV2{}
 			options.UsePathStyle = true
 		})
 	}
-	return client
+	return s3.NewFromConfig(*a.cfg)
 }
@@ -0,0 +1,75 @@
+package aws
+
+import (
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a simple bytes-per-second limiter shared across the
+// concurrent part uploads of a single multipart upload, so that
+// backup.uploader.maxBytesPerSecond caps the upload's total network impact
+// regardless of how many parts are in flight at once.
+type tokenBucket struct {
+	mu         sync.Mutex
+	tokens     float64
+	capacity   float64
+	refillRate float64 // bytes per second
+	last       time.Time
+}
+
+func newTokenBucket(bytesPerSecond int64) *tokenBucket {
+	capacity := float64(bytesPerSecond)
+	if capacity <= 0 {
+		capacity = 0 // unlimited
+	}
+	return &tokenBucket{tokens: capacity, capacity: capacity, refillRate: capacity, last: time.Now()}
+}
+
+// take blocks until n bytes' worth of tokens are available. A zero-capacity
+// bucket (no limit configured) never blocks. A read larger than the bucket's
+// capacity goes through once the bucket is full, leaving it in debt that
+// later refills pay off first.
+func (b *tokenBucket) take(n int) {
+	if b.capacity <= 0 {
+		return
+	}
+
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		elapsed := now.Sub(b.last).Seconds()
+		b.last = now
+		b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.refillRate)
+
+		if b.tokens >= float64(n) || b.tokens >= b.capacity {
+			b.tokens -= float64(n)
+			b.mu.Unlock()
+			return
+		}
+		deficit := float64(n) - b.tokens
+		wait := time.Duration(deficit / b.refillRate * float64(time.Second))
+		b.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// throttledReader wraps an io.Reader so every Read is metered against a
+// shared tokenBucket before the bytes are handed back to the caller.
+type throttledReader struct {
+	r      io.Reader
+	bucket *tokenBucket
+}
+
+func newThrottledReader(r io.Reader, bucket *tokenBucket) io.Reader {
+	return &throttledReader{r: r, bucket: bucket}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		t.bucket.take(n)
+	}
+	return n, err
+}
@@ -0,0 +1,59 @@
+package aws
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+const (
+	defaultMaxAttempts = 5
+	baseBackoff        = 200 * time.Millisecond
+	maxBackoff         = 20 * time.Second
+)
+
+// withRetry runs fn, retrying with exponential backoff and full jitter on
+// transient 5xx/throttling errors. Anything else (bad credentials, bucket
+// not found, ...) is returned immediately since retrying it can't help.
+func withRetry(attempts int, fn func() error) error {
+	if attempts <= 0 {
+		attempts = defaultMaxAttempts
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) || attempt == attempts-1 {
+			return err
+		}
+
+		backoff := time.Duration(math.Min(float64(maxBackoff), float64(baseBackoff)*math.Pow(2, float64(attempt))))
+		sleep := time.Duration(rand.Int63n(int64(backoff)))
+		time.Sleep(sleep)
+	}
+	return err
+}
+
+func isRetryable(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "SlowDown", "RequestTimeout", "ThrottlingException", "InternalError", "ServiceUnavailable":
+			return true
+		}
+	}
+
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) && respErr.HTTPStatusCode() >= http.StatusInternalServerError {
+		return true
+	}
+
+	return false
+}
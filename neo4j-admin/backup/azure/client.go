@@ -0,0 +1,122 @@
+// Package azure implements storage.Provider against Azure Blob Storage, so
+// that `backup.storage.provider=azure` can target a storage container with
+// the same backup CronJob/Job used for S3.
+package azure
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/common"
+)
+
+// azureClient talks to Azure Blob Storage using a *azblob.Client built from
+// the pod's managed identity or an account key/connection string.
+type azureClient struct {
+	client *azblob.Client
+}
+
+// NewClient builds an azureClient around an already-authenticated blob
+// client.
+func NewClient(client *azblob.Client) *azureClient {
+	return &azureClient{client: client}
+}
+
+// CheckBucketAccess checks if the given container (optionally
+// "container/prefix") is accessible or not. The name "bucketName" is kept to
+// match storage.Provider/the other backends even though Azure calls this a
+// container.
+func (a *azureClient) CheckBucketAccess(bucketName string) error {
+	containerName, prefix := splitContainerAndPrefix(bucketName)
+	ctx := context.TODO()
+
+	pager := a.client.NewListBlobsFlatPager(containerName, &azblob.ListBlobsFlatOptions{Prefix: &prefix})
+	if !pager.More() {
+		return fmt.Errorf("Unable to connect to azure container %s \n", bucketName)
+	}
+	page, err := pager.NextPage(ctx)
+	if err != nil {
+		return fmt.Errorf("Unable to connect to azure container %s \n Here's why: %v\n", bucketName, err)
+	}
+	if prefix != "" && len(page.Segment.BlobItems) == 0 {
+		return fmt.Errorf("azure container %s does not exist", bucketName)
+	}
+	log.Printf("Connectivity with Azure container '%s' established", bucketName)
+	return nil
+}
+
+// UploadFile uploads the file present at the provided location to the azure container
+func (a *azureClient) UploadFile(fileNames []string, bucketName string) error {
+	parentContainerName, _ := splitContainerAndPrefix(bucketName)
+	location := os.Getenv("LOCATION")
+	for _, fileName := range fileNames {
+		filePath := fmt.Sprintf("%s/%s", location, fileName)
+		yes, err := common.IsFileBigger(filePath)
+		if err != nil {
+			return err
+		}
+		if yes {
+			if err := a.UploadLargeObject(fileName, location, bucketName, parentContainerName); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := a.uploadBlob(filePath, parentContainerName, generateKeyName(bucketName, fileName)); err != nil {
+			return fmt.Errorf("Couldn't upload file %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, err)
+		}
+		log.Printf("File %s uploaded to azure container %s !!", fileName, bucketName)
+	}
+	return nil
+}
+
+// UploadLargeObject uploads a single large file to azure. azblob.Client's
+// UploadFile already splits the upload into blocks internally, so there is
+// no separate "large object" code path the way the AWS SDK needs one.
+func (a *azureClient) UploadLargeObject(fileName string, location string, bucketName string, parentBucketName string) error {
+	filePath := fmt.Sprintf("%s/%s", location, fileName)
+	log.Printf("Starting upload of file %s", filePath)
+	if err := a.uploadBlob(filePath, parentBucketName, generateKeyName(bucketName, fileName)); err != nil {
+		return fmt.Errorf("Couldn't upload large file %v to %v:%v. Here's why: %v\n", filePath, bucketName, fileName, err)
+	}
+	log.Printf("File (Large) %s uploaded to azure container %s !!", fileName, bucketName)
+	return nil
+}
+
+func (a *azureClient) uploadBlob(filePath string, containerName string, blobName string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("Couldn't open file %v to upload. Here's why: %v\n", filePath, err)
+	}
+	defer file.Close()
+
+	_, err = a.client.UploadFile(context.TODO(), containerName, blobName, file, nil)
+	return err
+}
+
+// GenerateEnvVariablesFromCredentials is a no-op for Azure: `neo4j-admin`
+// only needs AZURE_STORAGE_* env vars, which are expected to already be set
+// from the mounted Secret/managed identity.
+func (a *azureClient) GenerateEnvVariablesFromCredentials() error {
+	return nil
+}
+
+func generateKeyName(bucketName string, fileName string) string {
+	keyName := fileName
+	if strings.Contains(bucketName, "/") {
+		index := strings.Index(bucketName, "/")
+		keyName = fmt.Sprintf("%s/%s", bucketName[index+1:], fileName)
+	}
+	return keyName
+}
+
+func splitContainerAndPrefix(bucketName string) (name string, prefix string) {
+	if !strings.Contains(bucketName, "/") {
+		return bucketName, ""
+	}
+	firstIndex := strings.Index(bucketName, "/")
+	return bucketName[:firstIndex], bucketName[firstIndex+1:]
+}
@@ -0,0 +1,104 @@
+// Package cas implements a restic-style content-addressed chunk store on top
+// of an object storage bucket: backups are split into content-defined
+// chunks, each chunk is addressed by its SHA-256 digest, and only chunks the
+// bucket doesn't already hold are uploaded. A per-backup snapshot then
+// records the ordered list of chunk hashes needed to reconstruct the file.
+package cas
+
+import (
+	"bufio"
+	"io"
+)
+
+const (
+	// MinChunkSize is the smallest chunk the rolling hash will ever emit,
+	// other than a final short chunk at end-of-stream.
+	MinChunkSize = 512 * 1024
+	// AvgChunkSize is the chunk size the rolling hash targets on average.
+	AvgChunkSize = 1024 * 1024
+	// MaxChunkSize is a hard cap: the chunker force-cuts a chunk at this
+	// size even if the rolling hash hasn't found a boundary yet.
+	MaxChunkSize = 8 * 1024 * 1024
+
+	// maskBits is chosen so that, for data with no structure, a boundary is
+	// expected on average every 2^maskBits bytes == AvgChunkSize.
+	maskBits     = 20
+	boundaryMask = 1<<maskBits - 1
+)
+
+// gearTable is a fixed table of random 64-bit values used by the "gear"
+// rolling hash (Xia et al., FastCDC). It is a deterministic, precomputed
+// table rather than Rabin polynomial arithmetic because gear hashing gives
+// the same content-defined-chunking stability with far less per-byte work,
+// which matters here since backups can be many GiB.
+var gearTable = buildGearTable()
+
+func buildGearTable() [256]uint64 {
+	var table [256]uint64
+	// Deterministic LCG seed so every process chunks files identically -
+	// this table must never change once backups are taken against it, or
+	// old chunks will no longer dedup against new ones.
+	seed := uint64(0x9E3779B97F4A7C15)
+	for i := range table {
+		seed = seed*6364136223846793005 + 1442695040888963407
+		table[i] = seed
+	}
+	return table
+}
+
+// Chunk is one content-defined slice of a file: its byte offset within the
+// original file, its raw bytes, and the sha256 hex digest used as its key.
+type Chunk struct {
+	Offset int64
+	Data   []byte
+	Hash   string
+}
+
+// Chunker splits a stream into content-defined chunks with a rolling gear
+// hash, so that inserting or removing bytes anywhere in a file only
+// reshuffles the chunks touching that edit - the rest of the file still cuts
+// at the same boundaries and dedups against chunks already in the bucket.
+type Chunker struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+// NewChunker wraps r for chunked reading.
+func NewChunker(r io.Reader) *Chunker {
+	return &Chunker{r: bufio.NewReaderSize(r, MaxChunkSize)}
+}
+
+// Next returns the next chunk, or io.EOF once the stream is exhausted.
+func (c *Chunker) Next() (Chunk, error) {
+	var hash uint64
+	buf := make([]byte, 0, AvgChunkSize)
+	start := c.offset
+
+	for {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				if len(buf) == 0 {
+					return Chunk{}, io.EOF
+				}
+				return newChunk(start, buf), nil
+			}
+			return Chunk{}, err
+		}
+
+		buf = append(buf, b)
+		c.offset++
+		hash = (hash << 1) + gearTable[b]
+
+		atMin := len(buf) >= MinChunkSize
+		atBoundary := hash&boundaryMask == 0
+		atMax := len(buf) >= MaxChunkSize
+		if (atMin && atBoundary) || atMax {
+			return newChunk(start, buf), nil
+		}
+	}
+}
+
+func newChunk(offset int64, data []byte) Chunk {
+	return Chunk{Offset: offset, Data: data, Hash: sha256Hex(data)}
+}
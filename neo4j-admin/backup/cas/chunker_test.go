@@ -0,0 +1,100 @@
+package cas
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func chunkAll(t *testing.T, data []byte) []Chunk {
+	t.Helper()
+	chunker := NewChunker(bytes.NewReader(data))
+	var chunks []Chunk
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected chunking error: %v", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func randomBytes(n int, seed int64) []byte {
+	r := rand.New(rand.NewSource(seed))
+	data := make([]byte, n)
+	r.Read(data)
+	return data
+}
+
+func TestChunkerRespectsMinAndMaxSize(t *testing.T) {
+	data := randomBytes(32*1024*1024, 1)
+	chunks := chunkAll(t, data)
+
+	if len(chunks) < 2 {
+		t.Fatalf("expected multiple chunks for 32MiB of random data, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if len(chunk.Data) > MaxChunkSize {
+			t.Errorf("chunk %d exceeds MaxChunkSize: %d > %d", i, len(chunk.Data), MaxChunkSize)
+		}
+		// the final chunk may be shorter than MinChunkSize - the source ran
+		// out of bytes before a boundary was found.
+		if i != len(chunks)-1 && len(chunk.Data) < MinChunkSize {
+			t.Errorf("non-final chunk %d is smaller than MinChunkSize: %d < %d", i, len(chunk.Data), MinChunkSize)
+		}
+	}
+}
+
+// TestChunkBoundaryStability is the key dedup property: inserting bytes in
+// the middle of a file must not reshuffle chunk boundaries before the edit.
+// Content-defined chunking (as opposed to fixed-size chunking) guarantees
+// this, which is what lets an unchanged prefix dedup against a prior backup.
+func TestChunkBoundaryStability(t *testing.T) {
+	original := randomBytes(16*1024*1024, 42)
+	inserted := append(append([]byte{}, original[:4*1024*1024]...), randomBytes(1024, 99)...)
+	inserted = append(inserted, original[4*1024*1024:]...)
+
+	originalChunks := chunkAll(t, original)
+	insertedChunks := chunkAll(t, inserted)
+
+	originalHashes := make(map[string]bool, len(originalChunks))
+	for _, c := range originalChunks {
+		originalHashes[c.Hash] = true
+	}
+
+	shared := 0
+	for _, c := range insertedChunks {
+		if originalHashes[c.Hash] {
+			shared++
+		}
+	}
+
+	if shared == 0 {
+		t.Fatalf("expected at least some chunks to survive a localized insert, got 0 shared out of %d", len(insertedChunks))
+	}
+	// Only chunks overlapping the inserted region (and possibly one
+	// neighbour) should differ; the rest of a 16MiB file should dedup.
+	if shared < len(originalChunks)-2 {
+		t.Errorf("expected a small insert to only disturb a couple chunks, but only %d/%d chunks were shared", shared, len(originalChunks))
+	}
+}
+
+func TestChunkerIsDeterministic(t *testing.T) {
+	data := randomBytes(8*1024*1024, 7)
+	first := chunkAll(t, data)
+	second := chunkAll(t, data)
+
+	if len(first) != len(second) {
+		t.Fatalf("chunk count differs across runs: %d vs %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i].Hash != second[i].Hash {
+			t.Errorf("chunk %d hash differs across runs: %s vs %s", i, first[i].Hash, second[i].Hash)
+		}
+	}
+}
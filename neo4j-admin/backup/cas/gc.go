@@ -0,0 +1,154 @@
+package cas
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+const snapshotKeySuffix = ".snapshot.json"
+
+// chunkGCGracePeriod holds back any chunk object younger than this from
+// deletion, regardless of whether GC's reference scan found it referenced.
+// UploadFile writes a chunk before it finalizes the snapshot that references
+// it, so a GC pass racing a concurrent upload could otherwise see the chunk
+// but not yet the snapshot and delete data out from under it. An upload that
+// takes longer than this to go from chunk upload to snapshot finalize is not
+// covered: its older chunks are eligible for deletion.
+const chunkGCGracePeriod = 1 * time.Hour
+
+// GC reclaims chunks no longer referenced by any surviving snapshot
+// manifest in bucketName. It is a mark-and-sweep: first it reads every
+// "*.snapshot.json" manifest anywhere in the bucket - not just under one
+// backup's own prefix, since every tenant sharing a bucket shares the same
+// ChunkKeyPrefix namespace - to build the set of chunk hashes still in use,
+// then deletes every chunk object whose hash isn't in that set and whose
+// age clears chunkGCGracePeriod. encrypter must be the same one snapshots in
+// this bucket were written with, so SSE-C-protected manifests can be read.
+func GC(ctx context.Context, client s3API, bucketName string, encrypter Encrypter, now time.Time) (int, error) {
+	referenced, err := referencedChunkHashes(ctx, client, bucketName, encrypter)
+	if err != nil {
+		return 0, err
+	}
+
+	var toDelete []string
+	var continuationToken *string
+	for {
+		page, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(bucketName),
+			Prefix:            aws.String(ChunkKeyPrefix),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("Couldn't list chunks in bucket %s. Here's why: %v\n", bucketName, err)
+		}
+
+		for _, object := range page.Contents {
+			hash := chunkHashFromKey(aws.ToString(object.Key))
+			if hash == "" || referenced[hash] {
+				continue
+			}
+			if object.LastModified != nil && now.Sub(*object.LastModified) < chunkGCGracePeriod {
+				continue
+			}
+			toDelete = append(toDelete, aws.ToString(object.Key))
+		}
+
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		continuationToken = page.NextContinuationToken
+	}
+
+	if len(toDelete) == 0 {
+		log.Printf("Chunk GC of %s: nothing to reclaim (%d chunk hash(es) still referenced)", bucketName, len(referenced))
+		return 0, nil
+	}
+
+	const maxBatch = 1000
+	for start := 0; start < len(toDelete); start += maxBatch {
+		end := start + maxBatch
+		if end > len(toDelete) {
+			end = len(toDelete)
+		}
+		batch := toDelete[start:end]
+
+		objects := make([]types.ObjectIdentifier, len(batch))
+		for i, key := range batch {
+			objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+		}
+
+		output, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &types.Delete{Objects: objects},
+		})
+		if err != nil {
+			return 0, fmt.Errorf("Couldn't delete unreferenced chunks from bucket %s. Here's why: %v\n", bucketName, err)
+		}
+		for _, deleteErr := range output.Errors {
+			log.Printf("Couldn't delete chunk %s during GC: %s", aws.ToString(deleteErr.Key), aws.ToString(deleteErr.Message))
+		}
+	}
+
+	log.Printf("Chunk GC of %s: reclaimed %d chunk(s), %d chunk hash(es) still referenced", bucketName, len(toDelete), len(referenced))
+	return len(toDelete), nil
+}
+
+// referencedChunkHashes reads every snapshot manifest anywhere in
+// bucketName and returns the set of chunk hashes they collectively
+// reference.
+func referencedChunkHashes(ctx context.Context, client s3API, bucketName string, encrypter Encrypter) (map[string]bool, error) {
+	referenced := make(map[string]bool)
+	var continuationToken *string
+	for {
+		page, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(bucketName),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't list objects in bucket %s. Here's why: %v\n", bucketName, err)
+		}
+
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			if !strings.HasSuffix(key, snapshotKeySuffix) {
+				continue
+			}
+			snapshot, err := readSnapshot(ctx, client, bucketName, key, encrypter)
+			if err != nil {
+				return nil, err
+			}
+			for _, hash := range snapshot.Chunks {
+				referenced[hash] = true
+			}
+		}
+
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		continuationToken = page.NextContinuationToken
+	}
+
+	return referenced, nil
+}
+
+// chunkHashFromKey extracts a chunk's hash from its bucket key
+// (ChunkKeyPrefix + "aa/<hash>"), returning "" if key isn't shaped like a
+// chunk key.
+func chunkHashFromKey(key string) string {
+	rest := strings.TrimPrefix(key, ChunkKeyPrefix)
+	if rest == key {
+		return ""
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 2 || parts[1] == "" {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,40 @@
+package cas
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGCDeletesOnlyUnreferencedChunks(t *testing.T) {
+	client := newFakeS3()
+	store := NewStore(client, "bucket", "", nil)
+
+	keep := Chunk{Data: []byte("keep"), Hash: sha256Hex([]byte("keep"))}
+	drop := Chunk{Data: []byte("drop"), Hash: sha256Hex([]byte("drop"))}
+	for _, chunk := range []Chunk{keep, drop} {
+		if _, err := store.Put(context.Background(), chunk); err != nil {
+			t.Fatalf("store.Put: %v", err)
+		}
+	}
+
+	snapshot := Snapshot{FileName: "db.backup", Chunks: []string{keep.Hash}}
+	if err := finalizeSnapshot(context.Background(), client, "bucket", "db.backup.snapshot.json", snapshot, nil); err != nil {
+		t.Fatalf("finalizeSnapshot: %v", err)
+	}
+
+	deleted, err := GC(context.Background(), client, "bucket", nil, time.Unix(1_700_000_000, 0).UTC())
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+
+	if deleted != 1 {
+		t.Errorf("GC deleted %d chunk(s), want 1", deleted)
+	}
+	if _, ok := client.objects[chunkKey(keep.Hash)]; !ok {
+		t.Errorf("GC deleted chunk %s, which is still referenced by a snapshot", keep.Hash)
+	}
+	if _, ok := client.objects[chunkKey(drop.Hash)]; ok {
+		t.Errorf("expected unreferenced chunk %s to be reclaimed", drop.Hash)
+	}
+}
@@ -0,0 +1,90 @@
+package cas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// HasSnapshot reports whether fileName was backed up through the dedup path,
+// i.e. whether a snapshot manifest exists for it, without downloading
+// anything. The caller uses this to choose between Download (dedup) and a
+// plain GetObject (whole-file upload). encrypter must be the same one the
+// snapshot was written with (required for SSE-C; nil is a no-op), otherwise
+// S3 answers a HEAD against it with an error this package can't tell apart
+// from a genuine 404.
+func HasSnapshot(ctx context.Context, client s3API, parentBucketName string, bucketName string, fileName string, encrypter Encrypter) (bool, error) {
+	headInput := &s3.HeadObjectInput{
+		Bucket: aws.String(parentBucketName),
+		Key:    aws.String(snapshotKey(bucketName, fileName)),
+	}
+	if encrypter != nil {
+		encrypter.ApplyToHead(headInput)
+	}
+	_, err := client.HeadObject(ctx, headInput)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("Couldn't check for snapshot of %s in bucket %s. Here's why: %v\n", fileName, parentBucketName, err)
+	}
+	return true, nil
+}
+
+// Download is the inverse of UploadFile: it reads the snapshot manifest for
+// fileName and streams its chunks back, in order, into destPath - a dedup
+// restore never touches the chunk store's dedup bookkeeping, it just
+// reassembles bytes. encrypter must be the same one the snapshot and its
+// chunks were written with.
+func Download(ctx context.Context, client s3API, store *Store, parentBucketName string, bucketName string, fileName string, destPath string, encrypter Encrypter) error {
+	snapshot, err := readSnapshot(ctx, client, parentBucketName, snapshotKey(bucketName, fileName), encrypter)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("Couldn't create file %v to restore into. Here's why: %v\n", destPath, err)
+	}
+	defer out.Close()
+
+	for _, hash := range snapshot.Chunks {
+		body, err := store.Get(ctx, hash)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, body)
+		body.Close()
+		if err != nil {
+			return fmt.Errorf("Couldn't write chunk %s while restoring %s. Here's why: %v\n", hash, fileName, err)
+		}
+	}
+
+	return nil
+}
+
+func readSnapshot(ctx context.Context, client s3API, bucketName string, key string, encrypter Encrypter) (Snapshot, error) {
+	getInput := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	}
+	if encrypter != nil {
+		encrypter.ApplyToGet(getInput)
+	}
+	output, err := client.GetObject(ctx, getInput)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("Couldn't read snapshot %s in bucket %s. Here's why: %v\n", key, bucketName, err)
+	}
+	defer output.Body.Close()
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(output.Body).Decode(&snapshot); err != nil {
+		return Snapshot{}, fmt.Errorf("Couldn't parse snapshot %s in bucket %s. Here's why: %v\n", key, bucketName, err)
+	}
+	return snapshot, nil
+}
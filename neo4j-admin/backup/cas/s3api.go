@@ -0,0 +1,32 @@
+package cas
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3API is the subset of *s3.Client this package calls. Store and the
+// snapshot upload/download helpers take this instead of the concrete client
+// so tests can exercise the atomic-finalize and restore paths against an
+// in-memory fake instead of a real bucket.
+type s3API interface {
+	HeadObject(ctx context.Context, in *s3.HeadObjectInput, opts ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, in *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, in *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CopyObject(ctx context.Context, in *s3.CopyObjectInput, opts ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, opts ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, opts ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, opts ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// Encrypter applies the same server-side-encryption parameters the
+// whole-file upload path uses (see aws.encryptionConfig) to the chunk and
+// snapshot requests this package issues, so a dedup-stored backup gets
+// identical SSE-S3/SSE-KMS/SSE-C protection as a non-dedup one.
+type Encrypter interface {
+	ApplyToPut(*s3.PutObjectInput)
+	ApplyToCopy(*s3.CopyObjectInput)
+	ApplyToGet(*s3.GetObjectInput)
+	ApplyToHead(*s3.HeadObjectInput)
+}
@@ -0,0 +1,166 @@
+package cas
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Snapshot is the manifest of a single deduplicated backup: the ordered list
+// of chunk hashes needed to reconstruct the file, plus enough metadata to
+// restore it without a second round-trip to stat the original.
+type Snapshot struct {
+	FileName  string   `json:"fileName"`
+	Size      int64    `json:"size"`
+	SHA256    string   `json:"sha256"`
+	Chunks    []string `json:"chunks"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+// SnapshotKey returns the bucket key a file's snapshot manifest is stored
+// under, so callers outside this package (the retention/lifecycle wiring in
+// aws.UploadFile) can tag and lock the same object this package writes.
+func SnapshotKey(bucketName string, fileName string) string {
+	return snapshotKey(bucketName, fileName)
+}
+
+func snapshotKey(bucketName string, fileName string) string {
+	return fmt.Sprintf("%s.snapshot.json", generateKeyName(bucketName, fileName))
+}
+
+// UploadFile chunks the file at filePath with a content-defined chunker,
+// uploads every chunk the store doesn't already have, and writes a snapshot
+// object describing how to reassemble it. Chunks already present (because an
+// earlier backup uploaded identical pages) are skipped, which is what makes
+// successive backups of a mostly-unchanged graph cheap.
+//
+// Snapshot finalization is atomic: the manifest is first written to a
+// temporary key, then copied onto its real key with CopyObject, so a crash
+// mid-upload never leaves a half-written snapshot visible to a concurrent
+// restore.
+//
+// Chunking happens sequentially, one chunk at a time, so the whole-file
+// sha256 can be folded in chunk order as it goes - the same integrity
+// guarantee the non-dedup path gets from its streaming checksumHashingReader,
+// without a second pass over the file.
+func UploadFile(ctx context.Context, client s3API, store *Store, bucketName string, parentBucketName string, fileName string, filePath string, encrypter Encrypter) (Snapshot, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("Couldn't open file %v to upload. Here's why: %v\n", filePath, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	chunker := NewChunker(file)
+	fileHash := sha256.New()
+	var hashes []string
+	var uploadedChunks, skippedChunks int
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Snapshot{}, err
+		}
+
+		uploaded, err := store.Put(ctx, chunk)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		if uploaded {
+			uploadedChunks++
+		} else {
+			skippedChunks++
+		}
+		hashes = append(hashes, chunk.Hash)
+		fileHash.Write(chunk.Data)
+	}
+
+	snapshot := Snapshot{
+		FileName:  fileName,
+		Size:      info.Size(),
+		SHA256:    hex.EncodeToString(fileHash.Sum(nil)),
+		Chunks:    hashes,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if err := finalizeSnapshot(ctx, client, parentBucketName, snapshotKey(bucketName, fileName), snapshot, encrypter); err != nil {
+		return Snapshot{}, err
+	}
+
+	log.Printf("Backup %s deduplicated: %d chunk(s) uploaded, %d chunk(s) already present", fileName, uploadedChunks, skippedChunks)
+	return snapshot, nil
+}
+
+// finalizeSnapshot writes the manifest to a temporary key and then copies it
+// onto its real key, so readers never observe a partially-written snapshot.
+// Both the staged write and the copy carry the backup's encryption, so the
+// snapshot manifest is protected the same as the chunks it indexes.
+func finalizeSnapshot(ctx context.Context, client s3API, bucketName string, key string, snapshot Snapshot, encrypter Encrypter) error {
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tempKey := key + ".tmp"
+	putInput := &s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(tempKey),
+		Body:   bytes.NewReader(body),
+	}
+	if encrypter != nil {
+		encrypter.ApplyToPut(putInput)
+	}
+	if _, err := client.PutObject(ctx, putInput); err != nil {
+		return fmt.Errorf("Couldn't stage snapshot %s. Here's why: %v\n", key, err)
+	}
+
+	copyInput := &s3.CopyObjectInput{
+		Bucket:     aws.String(bucketName),
+		Key:        aws.String(key),
+		CopySource: aws.String(fmt.Sprintf("%s/%s", bucketName, tempKey)),
+	}
+	if encrypter != nil {
+		encrypter.ApplyToCopy(copyInput)
+	}
+	if _, err := client.CopyObject(ctx, copyInput); err != nil {
+		return fmt.Errorf("Couldn't finalize snapshot %s. Here's why: %v\n", key, err)
+	}
+
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(tempKey),
+	}); err != nil {
+		return fmt.Errorf("Couldn't clean up staged snapshot %s. Here's why: %v\n", tempKey, err)
+	}
+
+	return nil
+}
+
+// generateKeyName mirrors aws.generateKeyName: when bucketName carries a
+// "parent/prefix" path, the prefix becomes part of the object key and the
+// parent is the real bucket to address.
+func generateKeyName(bucketName string, fileName string) string {
+	keyName := fileName
+	if strings.Contains(bucketName, "/") {
+		index := strings.Index(bucketName, "/")
+		keyName = fmt.Sprintf("%s/%s", bucketName[index+1:], fileName)
+	}
+	return keyName
+}
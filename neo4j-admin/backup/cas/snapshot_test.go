@@ -0,0 +1,182 @@
+package cas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeS3 is a minimal in-memory stand-in for s3API, just enough to exercise
+// finalizeSnapshot's temp-key-then-copy sequence and Download's chunk
+// streaming without a real bucket.
+type fakeS3 struct {
+	objects     map[string][]byte
+	failCopy    bool
+	copyCalls   int
+	deleteCalls int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string][]byte)}
+}
+
+type notFoundErr struct{}
+
+func (notFoundErr) Error() string                 { return "not found" }
+func (notFoundErr) ErrorCode() string             { return "NoSuchKey" }
+func (notFoundErr) ErrorMessage() string          { return "not found" }
+func (notFoundErr) ErrorFault() smithy.ErrorFault { return smithy.FaultUnknown }
+
+func (f *fakeS3) HeadObject(ctx context.Context, in *s3.HeadObjectInput, opts ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	if _, ok := f.objects[*in.Key]; !ok {
+		return nil, notFoundErr{}
+	}
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, in *s3.GetObjectInput, opts ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	body, ok := f.objects[*in.Key]
+	if !ok {
+		return nil, notFoundErr{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, in *s3.PutObjectInput, opts ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	body, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[*in.Key] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) CopyObject(ctx context.Context, in *s3.CopyObjectInput, opts ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	f.copyCalls++
+	if f.failCopy {
+		return nil, errors.New("simulated CopyObject failure")
+	}
+	source := *in.CopySource
+	source = source[strings.Index(source, "/")+1:]
+	body, ok := f.objects[source]
+	if !ok {
+		return nil, notFoundErr{}
+	}
+	f.objects[*in.Key] = body
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, opts ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.deleteCalls++
+	delete(f.objects, *in.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, opts ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	prefix := ""
+	if in.Prefix != nil {
+		prefix = *in.Prefix
+	}
+	var contents []types.Object
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			contents = append(contents, types.Object{Key: aws.String(key)})
+		}
+	}
+	return &s3.ListObjectsV2Output{Contents: contents}, nil
+}
+
+func (f *fakeS3) DeleteObjects(ctx context.Context, in *s3.DeleteObjectsInput, opts ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	for _, object := range in.Delete.Objects {
+		f.deleteCalls++
+		delete(f.objects, *object.Key)
+	}
+	return &s3.DeleteObjectsOutput{}, nil
+}
+
+func TestFinalizeSnapshotIsAtomicViaTempKeyAndCopy(t *testing.T) {
+	client := newFakeS3()
+	snapshot := Snapshot{FileName: "db.backup", Size: 10, SHA256: "abc", Chunks: []string{"abc"}}
+
+	if err := finalizeSnapshot(context.Background(), client, "bucket", "db.backup.snapshot.json", snapshot, nil); err != nil {
+		t.Fatalf("finalizeSnapshot: %v", err)
+	}
+
+	if _, ok := client.objects["db.backup.snapshot.json.tmp"]; ok {
+		t.Errorf("expected the temporary key to be cleaned up after a successful finalize")
+	}
+	body, ok := client.objects["db.backup.snapshot.json"]
+	if !ok {
+		t.Fatalf("expected the real snapshot key to exist after finalize")
+	}
+	var got Snapshot
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("unmarshal finalized snapshot: %v", err)
+	}
+	if got.FileName != snapshot.FileName {
+		t.Errorf("finalized snapshot FileName = %q, want %q", got.FileName, snapshot.FileName)
+	}
+}
+
+func TestFinalizeSnapshotLeavesNoRealKeyWhenCopyFails(t *testing.T) {
+	client := newFakeS3()
+	client.failCopy = true
+	snapshot := Snapshot{FileName: "db.backup", Size: 10, Chunks: []string{"abc"}}
+
+	err := finalizeSnapshot(context.Background(), client, "bucket", "db.backup.snapshot.json", snapshot, nil)
+	if err == nil {
+		t.Fatalf("expected finalizeSnapshot to fail when CopyObject fails")
+	}
+
+	if _, ok := client.objects["db.backup.snapshot.json"]; ok {
+		t.Errorf("a failed finalize must never leave the real snapshot key visible - a concurrent restore would read a manifest that was never confirmed complete")
+	}
+	if _, ok := client.objects["db.backup.snapshot.json.tmp"]; !ok {
+		t.Errorf("expected the staged temp key to survive a failed finalize - it is only cleaned up after a successful copy")
+	}
+}
+
+func TestDownloadRestoresChunksInOrder(t *testing.T) {
+	client := newFakeS3()
+	store := NewStore(client, "bucket", "", nil)
+
+	chunks := []Chunk{
+		{Data: []byte("hello "), Hash: sha256Hex([]byte("hello "))},
+		{Data: []byte("world"), Hash: sha256Hex([]byte("world"))},
+	}
+	var hashes []string
+	for _, chunk := range chunks {
+		if _, err := store.Put(context.Background(), chunk); err != nil {
+			t.Fatalf("store.Put: %v", err)
+		}
+		hashes = append(hashes, chunk.Hash)
+	}
+
+	snapshot := Snapshot{FileName: "db.backup", Chunks: hashes}
+	if err := finalizeSnapshot(context.Background(), client, "bucket", "db.backup.snapshot.json", snapshot, nil); err != nil {
+		t.Fatalf("finalizeSnapshot: %v", err)
+	}
+
+	destPath := t.TempDir() + "/restored.backup"
+	if err := Download(context.Background(), client, store, "bucket", "db.backup", "db.backup", destPath, nil); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+
+	got, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatalf("read restored file: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("restored file = %q, want %q", got, "hello world")
+	}
+}
@@ -0,0 +1,228 @@
+package cas
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hexToBase64 re-encodes a sha256 hex digest as the base64 form S3 returns
+// in x-amz-checksum-sha256, so a chunk's own hash can be compared directly
+// against what PutObject echoes back.
+func hexToBase64(hexDigest string) (string, error) {
+	raw, err := hex.DecodeString(hexDigest)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+// ChunkKeyPrefix is the key namespace every chunk is stored under,
+// regardless of the backup's own prefix - the aws upload and download paths
+// build their Store with an empty prefix, so chunks land at the parent
+// bucket's root (see chunkKey) and every tenant sharing a bucket shares this
+// same namespace too. Exported so callers that walk a bucket for
+// reasons other than chunk storage (retention.listBackups, GC's own
+// reference scan) can recognise and skip/select chunk objects without
+// parsing a key's internal shape.
+const ChunkKeyPrefix = "chunks/"
+
+// chunkKey returns the bucket key a chunk is stored under: chunks/aa/<hash>,
+// where "aa" is the first byte of the hash hex-encoded. Spreading chunks
+// across these two-character prefixes avoids the hot-partition behaviour S3
+// exhibits when every object shares a common prefix.
+func chunkKey(hash string) string {
+	return fmt.Sprintf("%s%s/%s", ChunkKeyPrefix, hash[:2], hash)
+}
+
+// presenceCache is a small in-process LRU of chunk hashes already confirmed
+// present in the bucket, so a backup that re-chunks mostly-unchanged data
+// doesn't re-issue a HeadObject per chunk on every run within the same
+// process.
+type presenceCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+func newPresenceCache(capacity int) *presenceCache {
+	return &presenceCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *presenceCache) has(hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[hash]; ok {
+		c.order.MoveToFront(el)
+		return true
+	}
+	return false
+}
+
+func (c *presenceCache) add(hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[hash]; ok {
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[hash] = c.order.PushFront(hash)
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(string))
+		}
+	}
+}
+
+// defaultCacheSize bounds the presence cache so a single multi-TB backup
+// can't grow it unboundedly.
+const defaultCacheSize = 100_000
+
+// Store is a content-addressed chunk store layered over an S3 bucket.
+type Store struct {
+	client    s3API
+	bucket    string
+	prefix    string
+	cache     *presenceCache
+	encrypter Encrypter
+}
+
+// NewStore builds a Store that writes chunks into bucket/prefix (prefix may
+// be empty). encrypter may be nil, meaning chunks are written unencrypted
+// (backup.encryption.mode == "").
+func NewStore(client s3API, bucket string, prefix string, encrypter Encrypter) *Store {
+	return &Store{client: client, bucket: bucket, prefix: prefix, cache: newPresenceCache(defaultCacheSize), encrypter: encrypter}
+}
+
+func (s *Store) key(hash string) string {
+	key := chunkKey(hash)
+	if s.prefix != "" {
+		key = fmt.Sprintf("%s/%s", s.prefix, key)
+	}
+	return key
+}
+
+// Has reports whether a chunk with the given hash already exists in the
+// bucket, checking the in-process cache before falling back to a
+// HeadObject.
+func (s *Store) Has(ctx context.Context, hash string) (bool, error) {
+	if s.cache.has(hash) {
+		return true, nil
+	}
+	headInput := &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(hash)),
+	}
+	if s.encrypter != nil {
+		s.encrypter.ApplyToHead(headInput)
+	}
+	_, err := s.client.HeadObject(ctx, headInput)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("Couldn't check chunk %s in bucket %s. Here's why: %v\n", hash, s.bucket, err)
+	}
+	s.cache.add(hash)
+	return true, nil
+}
+
+// Put uploads a chunk if and only if it is not already present, so that
+// repeated backups against the same bucket only pay for the bytes that
+// actually changed. The chunk is written with the same server-side
+// encryption as the rest of the backup and its upload is verified against
+// S3's own checksum, just like the whole-file upload path.
+func (s *Store) Put(ctx context.Context, chunk Chunk) (uploaded bool, err error) {
+	present, err := s.Has(ctx, chunk.Hash)
+	if err != nil {
+		return false, err
+	}
+	if present {
+		return false, nil
+	}
+
+	putInput := &s3.PutObjectInput{
+		Bucket:            aws.String(s.bucket),
+		Key:               aws.String(s.key(chunk.Hash)),
+		Body:              bytes.NewReader(chunk.Data),
+		ChecksumAlgorithm: types.ChecksumAlgorithmSha256,
+	}
+	if s.encrypter != nil {
+		s.encrypter.ApplyToPut(putInput)
+	}
+	output, err := s.client.PutObject(ctx, putInput)
+	if err != nil {
+		return false, fmt.Errorf("Couldn't upload chunk %s to bucket %s. Here's why: %v\n", chunk.Hash, s.bucket, err)
+	}
+	if err := verifyChunkChecksum(chunk.Hash, output.ChecksumSHA256); err != nil {
+		return false, err
+	}
+	s.cache.add(chunk.Hash)
+	return true, nil
+}
+
+// verifyChunkChecksum compares a chunk's own hash against the checksum S3
+// echoed back for the PutObject that wrote it. A nil remote checksum means
+// S3 didn't return one and is treated as nothing to verify.
+func verifyChunkChecksum(chunkHash string, remoteChecksum *string) error {
+	if remoteChecksum == nil {
+		return nil
+	}
+	want, err := hexToBase64(chunkHash)
+	if err != nil {
+		return err
+	}
+	if want != *remoteChecksum {
+		return fmt.Errorf("checksum mismatch for chunk %s: uploaded object's sha256 (%s) does not match the chunk's own hash (%s) - the upload may be truncated or corrupted", chunkHash, *remoteChecksum, want)
+	}
+	return nil
+}
+
+// Get streams a chunk's bytes back, applying the same encryption headers it
+// was written with (required for SSE-C; a no-op for SSE-S3/SSE-KMS/plain).
+func (s *Store) Get(ctx context.Context, hash string) (io.ReadCloser, error) {
+	getInput := &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(hash)),
+	}
+	if s.encrypter != nil {
+		s.encrypter.ApplyToGet(getInput)
+	}
+	output, err := s.client.GetObject(ctx, getInput)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't download chunk %s from bucket %s. Here's why: %v\n", hash, s.bucket, err)
+	}
+	return output.Body, nil
+}
+
+func isNotFound(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey"
+	}
+	return false
+}
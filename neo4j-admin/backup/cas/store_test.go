@@ -0,0 +1,41 @@
+package cas
+
+import "testing"
+
+func TestPresenceCacheEvictsOldest(t *testing.T) {
+	cache := newPresenceCache(2)
+	cache.add("a")
+	cache.add("b")
+	cache.add("c") // should evict "a"
+
+	if cache.has("a") {
+		t.Errorf("expected \"a\" to have been evicted")
+	}
+	if !cache.has("b") || !cache.has("c") {
+		t.Errorf("expected \"b\" and \"c\" to still be cached")
+	}
+}
+
+func TestPresenceCacheRefreshesOnAccess(t *testing.T) {
+	cache := newPresenceCache(2)
+	cache.add("a")
+	cache.add("b")
+	cache.has("a") // touch "a" so it is no longer the least-recently-used
+	cache.add("c") // should evict "b", not "a"
+
+	if !cache.has("a") {
+		t.Errorf("expected \"a\" to survive eviction after being touched")
+	}
+	if cache.has("b") {
+		t.Errorf("expected \"b\" to have been evicted")
+	}
+}
+
+func TestChunkKeySpreadsByPrefix(t *testing.T) {
+	hash := "deadbeefcafebabe"
+	key := chunkKey(hash)
+	want := "chunks/de/deadbeefcafebabe"
+	if key != want {
+		t.Errorf("chunkKey(%q) = %q, want %q", hash, key, want)
+	}
+}
@@ -0,0 +1,118 @@
+// Package retention implements the backup chart's GFS (grandfather-father-son)
+// retention policy: after a successful upload, objects are tagged with their
+// backup type/db/timestamp, and a periodic sweep deletes anything outside the
+// configured daily/weekly/monthly generations, subject to a minimum-age
+// floor and, optionally, S3 Object Lock.
+package retention
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy is the GFS retention policy configured via `backup.retention.*`.
+type Policy struct {
+	Daily             int
+	Weekly            int
+	Monthly           int
+	MinRetentionHours int
+}
+
+// LoadPolicy reads the policy from the env vars the backup CronJob/Job is
+// wired with (RETENTION_DAILY, RETENTION_WEEKLY, RETENTION_MONTHLY,
+// RETENTION_MIN_HOURS), mirroring backup.retention.daily/.weekly/.monthly/
+// .minRetentionHours.
+func LoadPolicy() Policy {
+	return Policy{
+		Daily:             envInt("RETENTION_DAILY", 7),
+		Weekly:            envInt("RETENTION_WEEKLY", 4),
+		Monthly:           envInt("RETENTION_MONTHLY", 12),
+		MinRetentionHours: envInt("RETENTION_MIN_HOURS", 24),
+	}
+}
+
+func envInt(name string, fallback int) int {
+	value := strings.TrimSpace(os.Getenv(name))
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
+// Backup is the minimal information the retention policy needs about a
+// backup object: its key and when it was taken.
+type Backup struct {
+	Key       string
+	Timestamp time.Time
+}
+
+// SelectForDeletion applies the GFS policy to backups and returns the ones
+// that fall outside every generation (daily/weekly/monthly) and are also
+// older than MinRetentionHours, i.e. safe to delete.
+//
+// The algorithm walks the backups newest first and keeps each one in the
+// first generation (daily, then weekly ISO week, then monthly calendar
+// month) whose period it is the first to claim and which still has room
+// under its Daily/Weekly/Monthly count. A backup counts toward one
+// generation only: a backup kept as daily does not claim its week or month,
+// so the weekly and monthly picks come from backups the earlier generations
+// did not keep. A backup newer than MinRetentionHours is always kept regardless of the
+// generation counts, so a misconfigured policy (e.g. daily=0) can never
+// delete a backup taken minutes ago.
+func SelectForDeletion(backups []Backup, policy Policy, now time.Time) []Backup {
+	sorted := make([]Backup, len(backups))
+	copy(sorted, backups)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.After(sorted[j].Timestamp)
+	})
+
+	keep := make(map[string]bool, len(sorted))
+	dailySeen := make(map[string]bool)
+	weeklySeen := make(map[string]bool)
+	monthlySeen := make(map[string]bool)
+	minAge := time.Duration(policy.MinRetentionHours) * time.Hour
+
+	for _, backup := range sorted {
+		if now.Sub(backup.Timestamp) < minAge {
+			keep[backup.Key] = true
+			continue
+		}
+
+		dayKey := backup.Timestamp.Format("2006-01-02")
+		if !dailySeen[dayKey] && len(dailySeen) < policy.Daily {
+			dailySeen[dayKey] = true
+			keep[backup.Key] = true
+			continue
+		}
+
+		year, week := backup.Timestamp.ISOWeek()
+		weekKey := strconv.Itoa(year) + "-W" + strconv.Itoa(week)
+		if !weeklySeen[weekKey] && len(weeklySeen) < policy.Weekly {
+			weeklySeen[weekKey] = true
+			keep[backup.Key] = true
+			continue
+		}
+
+		monthKey := backup.Timestamp.Format("2006-01")
+		if !monthlySeen[monthKey] && len(monthlySeen) < policy.Monthly {
+			monthlySeen[monthKey] = true
+			keep[backup.Key] = true
+			continue
+		}
+	}
+
+	var toDelete []Backup
+	for _, backup := range sorted {
+		if !keep[backup.Key] {
+			toDelete = append(toDelete, backup)
+		}
+	}
+	return toDelete
+}
@@ -0,0 +1,68 @@
+package retention
+
+import (
+	"testing"
+	"time"
+)
+
+func backupsEveryDayFor(days int, now time.Time) []Backup {
+	backups := make([]Backup, days)
+	for i := 0; i < days; i++ {
+		backups[i] = Backup{
+			Key:       time.Duration(i).String(),
+			Timestamp: now.Add(-time.Duration(i) * 24 * time.Hour),
+		}
+	}
+	return backups
+}
+
+func TestSelectForDeletionKeepsRecentBackupsUnderMinRetention(t *testing.T) {
+	now := time.Date(2026, 7, 27, 12, 0, 0, 0, time.UTC)
+	policy := Policy{Daily: 0, Weekly: 0, Monthly: 0, MinRetentionHours: 48}
+	backups := []Backup{{Key: "recent", Timestamp: now.Add(-1 * time.Hour)}}
+
+	toDelete := SelectForDeletion(backups, policy, now)
+	if len(toDelete) != 0 {
+		t.Errorf("expected a backup within the minimum retention floor to never be deleted, got %v", toDelete)
+	}
+}
+
+func TestSelectForDeletionKeepsOneBackupPerDay(t *testing.T) {
+	now := time.Date(2026, 7, 27, 12, 0, 0, 0, time.UTC)
+	policy := Policy{Daily: 3, Weekly: 0, Monthly: 0, MinRetentionHours: 0}
+	backups := backupsEveryDayFor(10, now)
+
+	toDelete := SelectForDeletion(backups, policy, now)
+	if len(toDelete) != len(backups)-3 {
+		t.Errorf("expected 3 backups kept by the daily policy and %d deleted, got %d deleted", len(backups)-3, len(toDelete))
+	}
+}
+
+func TestSelectForDeletionFallsThroughToWeeklyAndMonthly(t *testing.T) {
+	now := time.Date(2026, 7, 27, 12, 0, 0, 0, time.UTC)
+	policy := Policy{Daily: 2, Weekly: 2, Monthly: 2, MinRetentionHours: 0}
+	backups := backupsEveryDayFor(120, now)
+
+	toDelete := SelectForDeletion(backups, policy, now)
+	kept := len(backups) - len(toDelete)
+	// At most one backup survives per generation count: 2 daily + 2 weekly +
+	// 2 monthly, though the same backup can't double count two generations
+	// since each backup takes only the first bucket it qualifies for.
+	if kept > 6 {
+		t.Errorf("expected at most 6 backups kept (2 daily + 2 weekly + 2 monthly), kept %d", kept)
+	}
+	if kept == 0 {
+		t.Errorf("expected at least some backups to be kept")
+	}
+}
+
+func TestSelectForDeletionIsEmptyWhenEverythingFitsPolicy(t *testing.T) {
+	now := time.Date(2026, 7, 27, 12, 0, 0, 0, time.UTC)
+	policy := Policy{Daily: 30, Weekly: 0, Monthly: 0, MinRetentionHours: 0}
+	backups := backupsEveryDayFor(10, now)
+
+	toDelete := SelectForDeletion(backups, policy, now)
+	if len(toDelete) != 0 {
+		t.Errorf("expected nothing to be deleted when the daily generation covers every backup, got %d", len(toDelete))
+	}
+}
@@ -0,0 +1,162 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/cas"
+)
+
+const (
+	tagBackupType = "backup-type"
+	tagDB         = "db"
+	tagTimestamp  = "ts"
+)
+
+// BackupType is the value of the `backup-type` tag applied to every
+// uploaded backup object.
+type BackupType string
+
+const (
+	Full        BackupType = "full"
+	Incremental BackupType = "incremental"
+)
+
+// TagObject tags a just-uploaded backup object with its type, database name
+// and creation time, so that Sweep (and anyone auditing the bucket by hand)
+// can tell what every object is without parsing its key.
+func TagObject(ctx context.Context, client *s3.Client, bucketName string, key string, backupType BackupType, db string, createdAt time.Time) error {
+	_, err := client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+		Tagging: &types.Tagging{
+			TagSet: []types.Tag{
+				{Key: aws.String(tagBackupType), Value: aws.String(string(backupType))},
+				{Key: aws.String(tagDB), Value: aws.String(db)},
+				{Key: aws.String(tagTimestamp), Value: aws.String(createdAt.UTC().Format(time.RFC3339))},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("Couldn't tag backup object %s in bucket %s. Here's why: %v\n", key, bucketName, err)
+	}
+	return nil
+}
+
+// listBackups lists every object under prefix that carries a `ts` tag and
+// returns it as a Backup. Objects without the tag (chunk store blobs,
+// manifests) are skipped - the retention sweep only ever acts on whole
+// backup objects and the dedup snapshots that stand in for them.
+func listBackups(ctx context.Context, client *s3.Client, bucketName string, prefix string) ([]Backup, error) {
+	var backups []Backup
+	var continuationToken *string
+
+	for {
+		page, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(bucketName),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: continuationToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't list objects in bucket %s under prefix %s. Here's why: %v\n", bucketName, prefix, err)
+		}
+
+		for _, object := range page.Contents {
+			if strings.HasPrefix(*object.Key, cas.ChunkKeyPrefix) {
+				// Chunks live in their own shared namespace at the parent
+				// bucket's root regardless of this backup's own prefix -
+				// they're never tagged, so skip them without spending a
+				// GetObjectTagging call per chunk.
+				continue
+			}
+			ts, ok, err := backupTimestamp(ctx, client, bucketName, *object.Key)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			backups = append(backups, Backup{Key: *object.Key, Timestamp: ts})
+		}
+
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		continuationToken = page.NextContinuationToken
+	}
+
+	return backups, nil
+}
+
+func backupTimestamp(ctx context.Context, client *s3.Client, bucketName string, key string) (time.Time, bool, error) {
+	tagging, err := client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("Couldn't read tags for %s in bucket %s. Here's why: %v\n", key, bucketName, err)
+	}
+
+	for _, tag := range tagging.TagSet {
+		if aws.ToString(tag.Key) != tagTimestamp {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, aws.ToString(tag.Value))
+		if err != nil {
+			return time.Time{}, false, nil
+		}
+		return ts, true, nil
+	}
+	return time.Time{}, false, nil
+}
+
+// Sweep lists every tagged backup object under bucketName/prefix, applies
+// policy to decide what falls outside the retained daily/weekly/monthly
+// generations, and deletes the rest in batches of up to 1000 keys (S3's
+// DeleteObjects limit).
+func Sweep(ctx context.Context, client *s3.Client, bucketName string, prefix string, policy Policy, now time.Time) error {
+	backups, err := listBackups(ctx, client, bucketName, prefix)
+	if err != nil {
+		return err
+	}
+
+	toDelete := SelectForDeletion(backups, policy, now)
+	if len(toDelete) == 0 {
+		log.Printf("Retention sweep of %s: nothing to delete (%d backup(s) retained)", bucketName, len(backups))
+		return nil
+	}
+
+	const maxBatch = 1000
+	for start := 0; start < len(toDelete); start += maxBatch {
+		end := start + maxBatch
+		if end > len(toDelete) {
+			end = len(toDelete)
+		}
+		batch := toDelete[start:end]
+
+		objects := make([]types.ObjectIdentifier, len(batch))
+		for i, backup := range batch {
+			objects[i] = types.ObjectIdentifier{Key: aws.String(backup.Key)}
+		}
+
+		output, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(bucketName),
+			Delete: &types.Delete{Objects: objects},
+		})
+		if err != nil {
+			return fmt.Errorf("Couldn't delete expired backups from bucket %s. Here's why: %v\n", bucketName, err)
+		}
+		for _, deleteErr := range output.Errors {
+			log.Printf("Couldn't delete %s during retention sweep: %s", aws.ToString(deleteErr.Key), aws.ToString(deleteErr.Message))
+		}
+	}
+
+	log.Printf("Retention sweep of %s: deleted %d backup(s), retained %d", bucketName, len(toDelete), len(backups)-len(toDelete))
+	return nil
+}
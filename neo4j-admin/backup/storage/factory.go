@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	gcsstorage "cloud.google.com/go/storage"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/aws"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/azure"
+	"github.com/neo4j/helm-charts/neo4j-admin/backup/gcs"
+)
+
+// NewProvider resolves `backup.storage.provider` (wired into the pod as the
+// STORAGE_PROVIDER env var) and builds the matching Provider, authenticating
+// each backend with its own default credential chain - IRSA/env for S3,
+// ENDPOINT/env for MinIO, workload identity/ADC for GCS, managed identity for
+// Azure - the same way each backend already resolves credentials on its own.
+// STORAGE_PROVIDER defaults to "s3" so existing deployments that never set it
+// keep working unchanged.
+func NewProvider(ctx context.Context) (Provider, error) {
+	name := Name(strings.TrimSpace(os.Getenv("STORAGE_PROVIDER")))
+	if name == "" {
+		name = S3
+	}
+
+	switch name {
+	case S3:
+		cfg, err := config.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't load AWS config for the s3 storage provider. Here's why: %v\n", err)
+		}
+		return aws.NewClient(&cfg), nil
+
+	case Minio:
+		region := strings.TrimSpace(os.Getenv("AWS_REGION"))
+		pathStyle := false
+		if value := strings.TrimSpace(os.Getenv("MINIO_PATH_STYLE")); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid MINIO_PATH_STYLE value %q: %v", value, err)
+			}
+			pathStyle = parsed
+		}
+		return aws.NewMinioClient(region, pathStyle)
+
+	case GCS:
+		client, err := gcsstorage.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't create GCS client for the gcs storage provider. Here's why: %v\n", err)
+		}
+		return gcs.NewClient(client), nil
+
+	case Azure:
+		account := strings.TrimSpace(os.Getenv("AZURE_STORAGE_ACCOUNT"))
+		if account == "" {
+			return nil, fmt.Errorf("backup.storage.provider=azure requires AZURE_STORAGE_ACCOUNT to be set")
+		}
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't resolve Azure credentials for the azure storage provider. Here's why: %v\n", err)
+		}
+		serviceURL := fmt.Sprintf("https://%s.blob.core.windows.net/", account)
+		client, err := azblob.NewClient(serviceURL, cred, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Couldn't create Azure Blob Storage client for account %v. Here's why: %v\n", account, err)
+		}
+		return azure.NewClient(client), nil
+
+	default:
+		return nil, ErrUnknownProvider(name)
+	}
+}
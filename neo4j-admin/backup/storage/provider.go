@@ -0,0 +1,46 @@
+// Package storage defines the provider-agnostic interface that the backup
+// tooling uploads and restores through. Each supported object store (AWS S3 /
+// MinIO, Google Cloud Storage, Azure Blob Storage) implements Provider so the
+// rest of neo4j-admin/backup never has to know which cloud it is talking to.
+package storage
+
+import "fmt"
+
+// Provider is implemented by every object storage backend the backup chart
+// can target. Implementations live in sibling packages (aws, gcs, azure).
+type Provider interface {
+	// CheckBucketAccess verifies that the configured bucket (optionally
+	// "bucket/prefix") is reachable with the credentials in use.
+	CheckBucketAccess(bucketName string) error
+
+	// UploadFile uploads each named file from the backup location to the
+	// bucket, choosing a large-object upload path automatically when a file
+	// exceeds the provider's single-PUT threshold.
+	UploadFile(fileNames []string, bucketName string) error
+
+	// UploadLargeObject uploads a single large file using the provider's
+	// multi-part/resumable upload mechanism.
+	UploadLargeObject(fileName string, location string, bucketName string, parentBucketName string) error
+
+	// GenerateEnvVariablesFromCredentials exports the provider's resolved
+	// credentials as environment variables so that `neo4j-admin` subprocesses
+	// (which only understand cloud-native env vars) can authenticate.
+	GenerateEnvVariablesFromCredentials() error
+}
+
+// Name identifies one of the supported storage providers. It is the value of
+// the Helm key `backup.storage.provider`.
+type Name string
+
+const (
+	S3    Name = "s3"
+	GCS   Name = "gcs"
+	Azure Name = "azure"
+	Minio Name = "minio"
+)
+
+// ErrUnknownProvider is returned by callers that resolve a Name to a
+// constructor and fail to recognise it.
+func ErrUnknownProvider(name Name) error {
+	return fmt.Errorf("unknown backup storage provider %q: must be one of %q, %q, %q, %q", name, S3, GCS, Azure, Minio)
+}